@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// migratedFromLabel marks every beta resource this tool applies (its value
+// is always "true"; the source v1alpha1 object is not recorded), so --prune
+// can later find and remove resources that are no longer produced by the
+// current conversion.
+const migratedFromLabel = "security.istio.io/migrated-from"
+
+var (
+	applyFlag      = flag.Bool("apply", false, "apply the converted beta policies to the cluster instead of printing them")
+	dryRun         = flag.String("dry-run", "", "passed through to the apply request; one of 'server' or 'client'")
+	fieldManager   = flag.String("field-manager", "security-policy-migrate", "field manager to use for the server-side apply request")
+	deleteSource   = flag.Bool("delete-source", false, "delete the source v1alpha1 Policy/MeshPolicy objects once their beta equivalent has been applied")
+	pruneFlag      = flag.Bool("prune", false, "delete previously applied beta resources that the current conversion no longer produces")
+	gvrPeerAuth    = schema.GroupVersionResource{Group: "security.istio.io", Version: "v1beta1", Resource: "peerauthentications"}
+	gvrRequestAuth = schema.GroupVersionResource{Group: "security.istio.io", Version: "v1beta1", Resource: "requestauthentications"}
+	gvrAuthzPolicy = schema.GroupVersionResource{Group: "security.istio.io", Version: "v1beta1", Resource: "authorizationpolicies"}
+)
+
+// appliedResource records a beta resource that was successfully applied,
+// along with enough information to delete it again if a later resource in
+// the same rollout fails and the rollout needs to roll back.
+type appliedResource struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// applyConvertedPolicies server-side applies the beta policies produced by
+// convert, installing root-namespace (mesh-scoped) resources before
+// namespace-scoped ones, rolling back everything it applied if any
+// resource in the rollout fails, and optionally deleting the v1alpha1
+// sources and pruning beta resources the current conversion no longer
+// produces.
+func (kc *kubeClient) applyConvertedPolicies(docs []string, sources []unstructured.Unstructured) error {
+	var objects []*unstructured.Unstructured
+	for _, doc := range docs {
+		obj, err := decodeUnstructured(doc)
+		if err != nil {
+			return fmt.Errorf("failed to parse converted policy before applying: %w", err)
+		}
+		if obj != nil {
+			objects = append(objects, obj)
+		}
+	}
+
+	// Install root-namespace (mesh-scoped) resources first, then the rest,
+	// matching the ordered rollout used for installing Istio itself.
+	var meshScoped, namespaceScoped []*unstructured.Unstructured
+	for _, obj := range objects {
+		if obj.GetNamespace() == kc.rootNamespace {
+			meshScoped = append(meshScoped, obj)
+		} else {
+			namespaceScoped = append(namespaceScoped, obj)
+		}
+	}
+
+	var applied []appliedResource
+	for _, group := range [][]*unstructured.Unstructured{meshScoped, namespaceScoped} {
+		for _, obj := range group {
+			gvr, err := gvrForKind(obj.GetKind())
+			if err != nil {
+				kc.rollback(applied)
+				return err
+			}
+			if err := kc.serverSideApply(gvr, obj); err != nil {
+				kc.rollback(applied)
+				return fmt.Errorf("failed to apply %s %s/%s, rolled back this rollout: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+			}
+			// --dry-run never actually persists anything (serverSideApply
+			// either skips the apiserver entirely or submits it with
+			// DryRunAll), so there's nothing here to roll back later and
+			// nothing real to report as APPLIED.
+			if *dryRun != "" {
+				continue
+			}
+			log.Printf("APPLIED %s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+			applied = append(applied, appliedResource{gvr: gvr, namespace: obj.GetNamespace(), name: obj.GetName()})
+		}
+	}
+
+	if *dryRun != "" {
+		if *deleteSource {
+			log.Printf("dry-run=%s: would delete %d source object(s)", *dryRun, len(sources))
+		}
+		if *pruneFlag {
+			log.Printf("dry-run=%s: skipping prune", *dryRun)
+		}
+		return nil
+	}
+
+	if *deleteSource {
+		kc.deleteSources(sources)
+	}
+	if *pruneFlag {
+		if err := kc.prune(applied); err != nil {
+			return fmt.Errorf("failed to prune stale beta resources: %w", err)
+		}
+	}
+	return nil
+}
+
+func gvrForKind(kind string) (schema.GroupVersionResource, error) {
+	switch kind {
+	case "PeerAuthentication":
+		return gvrPeerAuth, nil
+	case "RequestAuthentication":
+		return gvrRequestAuth, nil
+	case "AuthorizationPolicy":
+		return gvrAuthzPolicy, nil
+	default:
+		return schema.GroupVersionResource{}, fmt.Errorf("don't know how to apply resource kind %q", kind)
+	}
+}
+
+// serverSideApply applies obj with the migratedFromLabel set. --dry-run=client
+// never talks to the apiserver at all; --dry-run=server submits the apply
+// with DryRunAll so the apiserver validates it without persisting anything;
+// with no --dry-run, it applies for real and waits for the resource to be
+// accepted (its status subresource populated) before returning.
+func (kc *kubeClient) serverSideApply(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[migratedFromLabel] = "true"
+	obj.SetLabels(labels)
+
+	switch *dryRun {
+	case "client":
+		log.Printf("dry-run=client: would apply %s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		return nil
+	case "", "server":
+		// handled below
+	default:
+		return fmt.Errorf("invalid --dry-run value %q, must be 'server' or 'client'", *dryRun)
+	}
+
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal object: %w", err)
+	}
+
+	opts := metav1.PatchOptions{FieldManager: *fieldManager}
+	if *dryRun == "server" {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	if _, err := kc.dynamicClient.Resource(gvr).Namespace(obj.GetNamespace()).Patch(
+		context.TODO(), obj.GetName(), types.ApplyPatchType, data, opts); err != nil {
+		return err
+	}
+
+	if *dryRun == "server" {
+		log.Printf("dry-run=server: apiserver accepted %s %s/%s, nothing persisted", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		return nil
+	}
+	return kc.waitForAccepted(gvr, obj)
+}
+
+// waitForAccepted polls the applied resource until the apiserver has
+// populated its status subresource, or 30 seconds elapse.
+func (kc *kubeClient) waitForAccepted(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	return wait.PollImmediate(time.Second, 30*time.Second, func() (bool, error) {
+		applied, err := kc.dynamicClient.Resource(gvr).Namespace(obj.GetNamespace()).Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		_, found, _ := unstructured.NestedMap(applied.Object, "status")
+		return found, nil
+	})
+}
+
+// rollback deletes every resource that was successfully applied earlier in
+// a rollout that subsequently failed.
+func (kc *kubeClient) rollback(applied []appliedResource) {
+	for _, a := range applied {
+		if err := kc.dynamicClient.Resource(a.gvr).Namespace(a.namespace).Delete(context.TODO(), a.name, metav1.DeleteOptions{}); err != nil && !kerr.IsNotFound(err) {
+			log.Printf("failed to roll back %s %s/%s: %v", a.gvr.Resource, a.namespace, a.name, err)
+			continue
+		}
+		log.Printf("ROLLED BACK %s %s/%s", a.gvr.Resource, a.namespace, a.name)
+	}
+}
+
+// sourcePolicyGVRs maps the v1alpha1 authentication policy kinds to their
+// registered GVR, matching gvrPolicies in k8s.go. Naive pluralization
+// ("Policy" -> "policys") doesn't match the real resource names, so
+// deleteSources looks them up here instead of guessing.
+var sourcePolicyGVRs = map[string]schema.GroupVersionResource{
+	"Policy":     gvrPolicies[0],
+	"MeshPolicy": gvrPolicies[1],
+}
+
+// deleteSources removes the v1alpha1 Policy/MeshPolicy objects a successful
+// rollout was converted from.
+func (kc *kubeClient) deleteSources(sources []unstructured.Unstructured) {
+	for _, item := range sources {
+		gvr, ok := sourcePolicyGVRs[item.GetKind()]
+		if !ok {
+			log.Printf("don't know the GVR for source kind %q, skipping delete of %s/%s", item.GetKind(), item.GetNamespace(), item.GetName())
+			continue
+		}
+		if err := kc.dynamicClient.Resource(gvr).Namespace(item.GetNamespace()).Delete(context.TODO(), item.GetName(), metav1.DeleteOptions{}); err != nil && !kerr.IsNotFound(err) {
+			log.Printf("failed to delete source %s %s/%s: %v", item.GetKind(), item.GetNamespace(), item.GetName(), err)
+			continue
+		}
+		log.Printf("DELETED source %s %s/%s", item.GetKind(), item.GetNamespace(), item.GetName())
+	}
+}
+
+// prune deletes beta resources carrying migratedFromLabel that the current
+// conversion did not just apply, so stale PeerAuthentication/
+// RequestAuthentication objects don't linger after their source policy is
+// removed or narrowed.
+func (kc *kubeClient) prune(applied []appliedResource) error {
+	current := map[string]bool{}
+	for _, a := range applied {
+		current[a.gvr.String()+"/"+a.namespace+"/"+a.name] = true
+	}
+
+	for _, gvr := range []schema.GroupVersionResource{gvrPeerAuth, gvrRequestAuth, gvrAuthzPolicy} {
+		objectList, err := kc.dynamicClient.Resource(gvr).Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+			LabelSelector: migratedFromLabel,
+		})
+		if err != nil {
+			return err
+		}
+		for _, item := range objectList.Items {
+			key := gvr.String() + "/" + item.GetNamespace() + "/" + item.GetName()
+			if current[key] {
+				continue
+			}
+			if err := kc.dynamicClient.Resource(gvr).Namespace(item.GetNamespace()).Delete(context.TODO(), item.GetName(), metav1.DeleteOptions{}); err != nil && !kerr.IsNotFound(err) {
+				return err
+			}
+			log.Printf("PRUNED %s %s/%s", item.GetKind(), item.GetNamespace(), item.GetName())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	inputDir          = flag.String("input-dir", "", "read Policy/MeshPolicy/RBAC/Service manifests from this directory instead of a live cluster")
+	inputFile         = flag.String("input-file", "", "read Policy/MeshPolicy/RBAC/Service manifests from this file instead of a live cluster")
+	fromStdin         = flag.Bool("from-stdin", false, "read Policy/MeshPolicy/RBAC/Service manifests from stdin instead of a live cluster")
+	meshConfigFile    = flag.String("mesh-config-file", "", "path to a MeshConfig file used to discover the mesh root namespace when running without a cluster")
+	rootNamespaceFlag = flag.String("root-namespace", "", "override the mesh root namespace instead of discovering it from --mesh-config-file or a live cluster")
+	outputDirFlag     = flag.String("output-dir", "", "write converted beta policies to this directory instead of stdout")
+)
+
+// runFileConvert is the entry point for offline conversion: it is invoked
+// instead of kubeClient.convert whenever --input-dir, --input-file, or
+// --from-stdin is set, so manifests checked into Git (or piped in) can be
+// migrated without cluster access.
+func runFileConvert() error {
+	fc, err := newFileConverter(*inputDir, *inputFile, *meshConfigFile, *rootNamespaceFlag, *outputDirFlag)
+	if err != nil {
+		return err
+	}
+	return fc.convert()
+}
+
+// fileConverter mirrors kubeClient but reads its input manifests from a
+// directory, a single file, or stdin instead of talking to a live cluster.
+// It is used by the --input-dir / --input-file modes so the tool can run
+// against Istio configuration checked into Git without needing kubectl
+// access to the mesh.
+type fileConverter struct {
+	rootNamespace string
+	services      *corev1.ServiceList
+	policies      []unstructured.Unstructured
+	rbac          []unstructured.Unstructured
+	outputDir     string
+}
+
+// newFileConverter loads every YAML/JSON manifest found in inputDir or
+// inputFile (or, if both are empty, stdin), buckets them by kind, and
+// resolves the mesh root namespace from meshConfigFile or rootNamespaceFlag.
+func newFileConverter(inputDir, inputFile, meshConfigFile, rootNamespaceFlag, outputDir string) (*fileConverter, error) {
+	docs, err := readManifests(inputDir, inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input manifests: %w", err)
+	}
+
+	fc := &fileConverter{outputDir: outputDir}
+	var serviceItems []corev1.Service
+	for _, doc := range docs {
+		obj, err := decodeUnstructured(doc)
+		if err != nil {
+			log.Printf("skipped manifest that could not be parsed: %v", err)
+			continue
+		}
+		if obj == nil {
+			continue
+		}
+		switch obj.GetKind() {
+		case "Policy", "MeshPolicy":
+			fc.policies = append(fc.policies, *obj)
+		case "RbacConfig", "ClusterRbacConfig", "ServiceRole", "ServiceRoleBinding":
+			fc.rbac = append(fc.rbac, *obj)
+		case "Service":
+			var svc corev1.Service
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &svc); err != nil {
+				log.Printf("skipped Service %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+				continue
+			}
+			serviceItems = append(serviceItems, svc)
+		}
+	}
+	fc.services = &corev1.ServiceList{Items: serviceItems}
+
+	rootNamespace, err := resolveRootNamespace(meshConfigFile, rootNamespaceFlag)
+	if err != nil {
+		return nil, err
+	}
+	fc.rootNamespace = rootNamespace
+
+	return fc, nil
+}
+
+// resolveRootNamespace prefers an explicit --root-namespace flag, then falls
+// back to the rootNamespace field of a supplied MeshConfig file, and
+// finally defaults to istioNamespace, matching kubeClient.setRootnamespace.
+func resolveRootNamespace(meshConfigFile, rootNamespaceFlag string) (string, error) {
+	if rootNamespaceFlag != "" {
+		return rootNamespaceFlag, nil
+	}
+	if meshConfigFile == "" {
+		log.Printf("no MeshConfig file or --root-namespace given, using %s as default root namespace", istioNamespace)
+		return istioNamespace, nil
+	}
+
+	raw, err := ioutil.ReadFile(meshConfigFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read mesh config file (%s): %w", meshConfigFile, err)
+	}
+	jsonObject := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &jsonObject); err != nil {
+		return "", fmt.Errorf("failed to parse mesh config file (%s): %w", meshConfigFile, err)
+	}
+	if val, found := jsonObject["rootNamespace"]; found && val != nil {
+		if v, ok := val.(string); ok && v != "" {
+			log.Printf("found root namespace: %s", v)
+			return v, nil
+		}
+	}
+	log.Printf("mesh config file (%s) has no rootNamespace, using %s as default", meshConfigFile, istioNamespace)
+	return istioNamespace, nil
+}
+
+// readManifests returns the raw YAML/JSON documents found under inputDir or
+// inputFile, falling back to stdin when neither is set (selected via
+// --from-stdin).
+func readManifests(inputDir, inputFile string) ([]string, error) {
+	switch {
+	case inputDir != "":
+		var docs []string
+		err := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+				return nil
+			}
+			raw, err := ioutil.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			docs = append(docs, splitYAMLDocuments(string(raw))...)
+			return nil
+		})
+		return docs, err
+	case inputFile != "":
+		raw, err := ioutil.ReadFile(inputFile)
+		if err != nil {
+			return nil, err
+		}
+		return splitYAMLDocuments(string(raw)), nil
+	default:
+		raw, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return splitYAMLDocuments(string(raw)), nil
+	}
+}
+
+func splitYAMLDocuments(raw string) []string {
+	var docs []string
+	for _, doc := range strings.Split(raw, "\n---\n") {
+		if strings.TrimSpace(doc) != "" {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+func decodeUnstructured(doc string) (*unstructured.Unstructured, error) {
+	jsonData, err := yaml.YAMLToJSON([]byte(doc))
+	if err != nil {
+		return nil, fmt.Errorf("failed converting YAML to JSON: %w", err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(jsonData); err != nil {
+		return nil, fmt.Errorf("failed unmarshaling object: %w", err)
+	}
+	if obj.GetKind() == "" {
+		return nil, nil
+	}
+	return obj, nil
+}
+
+// convert runs the same authentication policy conversion as
+// kubeClient.convert, sourcing its input from parsed manifests rather than
+// a live cluster, and writes the beta policies to stdout or --output-dir.
+func (fc *fileConverter) convert() error {
+	converter := newConverter(fc.rootNamespace, fc.services)
+	hasError := false
+	var resources []migratedResource
+	var statuses []resourceStatus
+	for _, item := range fc.policies {
+		policy, err := convertToPolicy(item)
+		if err != nil {
+			return fmt.Errorf("failed to convert resource to authentication policy: %v", err)
+		}
+		output, summary := converter.Convert(policy)
+		status := resourceStatus{SourceKind: item.GetKind(), SourceNamespace: item.GetNamespace(), SourceName: item.GetName()}
+		if cnt := len(summary.errors); cnt != 0 {
+			errorOutput := fmt.Sprintf("\n\t* %s", strings.Join(summary.errors, "\n\t* "))
+			log.Printf("FAILED  converting policy %s/%s, found %d errors: %s", item.GetNamespace(), item.GetName(), cnt, errorOutput)
+			hasError = true
+			status.Status = statusFailed
+			status.Errors = summary.errors
+			statuses = append(statuses, status)
+			continue
+		}
+		log.Printf("SUCCESS converting policy %s/%s", item.GetNamespace(), item.GetName())
+		status.Status = statusConverted
+		for _, out := range output {
+			r, err := toMigratedResource(out.toYAML())
+			if err != nil {
+				return fmt.Errorf("failed to parse converted policy %s/%s: %w", item.GetNamespace(), item.GetName(), err)
+			}
+			resources = append(resources, r)
+		}
+		statuses = append(statuses, status)
+	}
+
+	if len(fc.rbac) != 0 {
+		var rbacResources []string
+		for _, item := range fc.rbac {
+			rbacResources = append(rbacResources, fmt.Sprintf("%s: %s/%s", item.GetKind(), item.GetNamespace(), item.GetName()))
+			statuses = append(statuses, resourceStatus{
+				SourceKind:      item.GetKind(),
+				SourceNamespace: item.GetNamespace(),
+				SourceName:      item.GetName(),
+				Status:          statusSkipped,
+				Errors:          []string{"RBAC conversion from file input is not yet supported, convert against a live cluster instead"},
+			})
+		}
+		errorOutput := fmt.Sprintf("\n\t* %s", strings.Join(rbacResources, "\n\t* "))
+		log.Printf("FAILED  found %d RBAC resources, RBAC conversion is only supported against a live cluster, "+
+			"check https://istio.io/latest/blog/2019/v1beta1-authorization-policy/#migration-from-the-v1alpha1-policy for converting RBAC resources manually: %s", len(rbacResources), errorOutput)
+		hasError = true
+	}
+
+	if hasError {
+		if ignoreError {
+			log.Printf("Found errors but ignored with --ignore-error, the converted policies may not work as expected")
+		} else {
+			return fmt.Errorf("conversion failed, found errors during conversion, please fix errors and re-run the tool again")
+		}
+	}
+
+	writer, err := newOutputWriter(*outputFormat, fc.outputDir)
+	if err != nil {
+		return err
+	}
+	return writer.Write(resources, statuses)
+}
+
+func writeOutputFile(dir, name, content string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory (%s): %w", dir, err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write output file (%s): %w", path, err)
+	}
+	log.Printf("wrote converted policies to %s", path)
+	return nil
+}
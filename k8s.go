@@ -125,7 +125,9 @@ func (kc *kubeClient) convert() error {
 	}
 	converter := newConverter(kc.rootNamespace, services)
 	hasError := false
-	var betaPolicyOutput strings.Builder
+	var resources []migratedResource
+	var statuses []resourceStatus
+	var sourcePolicies []unstructured.Unstructured
 	for _, gvr := range gvrPolicies {
 		objectList, err := kc.listResources(gvr)
 		if err != nil {
@@ -138,34 +140,65 @@ func (kc *kubeClient) convert() error {
 				return fmt.Errorf("failed to convert resource to authentication policy: %v", err)
 			}
 			output, summary := converter.Convert(policy)
+			status := resourceStatus{SourceKind: item.GetKind(), SourceNamespace: item.GetNamespace(), SourceName: item.GetName()}
 			if cnt := len(summary.errors); cnt != 0 {
 				errorOutput := fmt.Sprintf("\n\t* %s", strings.Join(summary.errors, "\n\t* "))
 				log.Printf("FAILED  converting policy %s/%s, found %d errors: %s", item.GetNamespace(), item.GetName(), cnt, errorOutput)
 				hasError = true
+				status.Status = statusFailed
+				status.Errors = summary.errors
 			} else {
 				log.Printf("SUCCESS converting policy %s/%s", item.GetNamespace(), item.GetName())
+				status.Status = statusConverted
+				sourcePolicies = append(sourcePolicies, item)
 				for _, out := range output {
-					betaPolicyOutput.WriteString(out.toYAML())
+					r, err := toMigratedResource(out.toYAML())
+					if err != nil {
+						return fmt.Errorf("failed to parse converted policy %s/%s: %w", item.GetNamespace(), item.GetName(), err)
+					}
+					resources = append(resources, r)
 				}
 			}
+			statuses = append(statuses, status)
 		}
 	}
 
-	var rbacResources []string
+	rbacByKind := map[string][]unstructured.Unstructured{}
 	for _, gvr := range gvrRbac {
 		objectList, err := kc.listResources(gvr)
 		if err != nil {
+			log.Printf("skipped resource %s: %v", gvr.Resource, err)
 			continue
 		}
 		for _, item := range objectList.Items {
-			rbacResources = append(rbacResources, fmt.Sprintf("%s: %s/%s", item.GetKind(), item.GetNamespace(), item.GetName()))
+			rbacByKind[item.GetKind()] = append(rbacByKind[item.GetKind()], item)
 		}
 	}
-	if len(rbacResources) != 0 {
-		errorOutput := fmt.Sprintf("\n\t* %s", strings.Join(rbacResources, "\n\t* "))
-		log.Printf("FAILED  found %d RBAC resources, this tool only supports converting authentication policy, "+
-			"check https://istio.io/latest/blog/2019/v1beta1-authorization-policy/#migration-from-the-v1alpha1-policy for converting RBAC resources manually: %s", len(rbacResources), errorOutput)
-		hasError = true
+	rbacConfigs := append(rbacByKind["RbacConfig"], rbacByKind["ClusterRbacConfig"]...)
+	if len(rbacConfigs) != 0 {
+		rbacConverter := newRbacConverter(services)
+		for _, rbacConfig := range rbacConfigs {
+			output, summary := rbacConverter.Convert(rbacConfig, rbacByKind["ServiceRole"], rbacByKind["ServiceRoleBinding"])
+			status := resourceStatus{SourceKind: rbacConfig.GetKind(), SourceNamespace: rbacConfig.GetNamespace(), SourceName: rbacConfig.GetName()}
+			if cnt := len(summary.errors); cnt != 0 {
+				errorOutput := fmt.Sprintf("\n\t* %s", strings.Join(summary.errors, "\n\t* "))
+				log.Printf("FAILED  converting RBAC config %s/%s, found %d errors: %s", rbacConfig.GetNamespace(), rbacConfig.GetName(), cnt, errorOutput)
+				hasError = true
+				status.Status = statusFailed
+				status.Errors = summary.errors
+			} else {
+				log.Printf("SUCCESS converting RBAC config %s/%s", rbacConfig.GetNamespace(), rbacConfig.GetName())
+				status.Status = statusConverted
+			}
+			for _, out := range output {
+				r, err := toMigratedResource(out.toYAML())
+				if err != nil {
+					return fmt.Errorf("failed to parse converted RBAC config %s/%s: %w", rbacConfig.GetNamespace(), rbacConfig.GetName(), err)
+				}
+				resources = append(resources, r)
+			}
+			statuses = append(statuses, status)
+		}
 	}
 
 	if hasError {
@@ -176,8 +209,24 @@ func (kc *kubeClient) convert() error {
 			return fmt.Errorf("conversion failed, found errors during conversion, please fix errors and re-run the tool again")
 		}
 	}
-	fmt.Printf(betaPolicyOutput.String())
-	return nil
+
+	if *applyFlag {
+		var docs []string
+		for _, r := range resources {
+			docs = append(docs, r.yaml)
+		}
+		return kc.applyConvertedPolicies(docs, sourcePolicies)
+	}
+
+	if *diffFlag {
+		return kc.diffAgainstCluster(resources)
+	}
+
+	writer, err := newOutputWriter(*outputFormat, *outputDirFlag)
+	if err != nil {
+		return err
+	}
+	return writer.Write(resources, statuses)
 }
 
 func (kc *kubeClient) listResources(gvr schema.GroupVersionResource) (*unstructured.UnstructuredList, error) {
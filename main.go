@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+)
+
+var (
+	kubeconfig    = flag.String("kubeconfig", "", "path to a kubeconfig file; defaults to the standard kubeconfig resolution")
+	configContext = flag.String("context", "", "kubeconfig context to use")
+)
+
+func main() {
+	flag.Parse()
+
+	if *precheckOnly {
+		kc, err := newKubeClient(*kubeconfig, *configContext)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := runPrecheck(kc); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *inputDir != "" || *inputFile != "" || *fromStdin {
+		if err := runFileConvert(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	kc, err := newKubeClient(*kubeconfig, *configContext)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := kc.convert(); err != nil {
+		log.Fatal(err)
+	}
+}
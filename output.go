@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	outputFormat = flag.String("output-format", "yaml", "format for the converted policies: yaml, json, kustomize, or helm")
+	diffFlag     = flag.Bool("diff", false, "diff the converted policies against what is already in the cluster instead of writing them")
+)
+
+// migratedResource is one beta policy produced by either converter, kept
+// alongside enough metadata to render it in any output format or diff it
+// against the cluster.
+type migratedResource struct {
+	kind      string
+	namespace string
+	name      string
+	yaml      string
+}
+
+// resourceStatus records, per source v1alpha1 object, whether conversion
+// succeeded, so migration-report.json gives GitOps tooling a machine
+// readable summary of the run.
+type resourceStatus struct {
+	SourceKind      string   `json:"sourceKind"`
+	SourceNamespace string   `json:"sourceNamespace"`
+	SourceName      string   `json:"sourceName"`
+	Status          string   `json:"status"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+const (
+	statusConverted = "converted"
+	statusFailed    = "failed"
+	statusSkipped   = "skipped"
+)
+
+// OutputWriter renders the resources produced by a conversion run to
+// wherever the operator asked for them: stdout, a flat output directory,
+// or a structured bundle such as a Kustomize overlay or Helm chart. Every
+// implementation also emits a migration report summarizing the run: as
+// migration-report.json in the output directory, or to the log when there
+// is none.
+type OutputWriter interface {
+	Write(resources []migratedResource, statuses []resourceStatus) error
+}
+
+// newOutputWriter resolves --output-format (and --output-dir, shared with
+// the file-converter flag of the same name) into the writer that should
+// render a conversion run's results.
+func newOutputWriter(format, outputDir string) (OutputWriter, error) {
+	switch format {
+	case "", "yaml":
+		return yamlWriter{outputDir: outputDir}, nil
+	case "json":
+		return jsonWriter{outputDir: outputDir}, nil
+	case "kustomize":
+		return kustomizeWriter{outputDir: outputDir}, nil
+	case "helm":
+		return helmWriter{outputDir: outputDir}, nil
+	default:
+		return nil, fmt.Errorf("unknown --output-format %q, expected yaml, json, kustomize, or helm", format)
+	}
+}
+
+func writeMigrationReport(outputDir string, statuses []resourceStatus) error {
+	report, err := json.MarshalIndent(statuses, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal migration report: %w", err)
+	}
+	if outputDir == "" {
+		log.Printf("migration report:\n%s", report)
+		return nil
+	}
+	return writeOutputFile(outputDir, "migration-report.json", string(report))
+}
+
+// yamlWriter is the tool's original behaviour: every resource concatenated
+// as YAML documents, either to stdout or to a single file.
+type yamlWriter struct {
+	outputDir string
+}
+
+func (w yamlWriter) Write(resources []migratedResource, statuses []resourceStatus) error {
+	var sb strings.Builder
+	for _, r := range resources {
+		sb.WriteString(r.yaml)
+	}
+	if w.outputDir == "" {
+		fmt.Printf(sb.String())
+	} else if err := writeOutputFile(w.outputDir, "migrated-policies.yaml", sb.String()); err != nil {
+		return err
+	}
+	return writeMigrationReport(w.outputDir, statuses)
+}
+
+// jsonWriter renders every resource's YAML as the equivalent JSON object in
+// a single JSON array, for tooling that would rather not parse YAML.
+type jsonWriter struct {
+	outputDir string
+}
+
+func (w jsonWriter) Write(resources []migratedResource, statuses []resourceStatus) error {
+	var objects []map[string]interface{}
+	for _, r := range resources {
+		obj, err := yamlDocToJSONObject(r.yaml)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s %s/%s to JSON: %w", r.kind, r.namespace, r.name, err)
+		}
+		objects = append(objects, obj)
+	}
+	data, err := json.MarshalIndent(objects, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal converted policies to JSON: %w", err)
+	}
+	if w.outputDir == "" {
+		fmt.Println(string(data))
+	} else if err := writeOutputFile(w.outputDir, "migrated-policies.json", string(data)); err != nil {
+		return err
+	}
+	return writeMigrationReport(w.outputDir, statuses)
+}
+
+// kustomizeWriter lays each resource out as its own file under bases/, with
+// a kustomization.yaml listing them, so the result can be used directly as
+// a Kustomize base.
+type kustomizeWriter struct {
+	outputDir string
+}
+
+func (w kustomizeWriter) Write(resources []migratedResource, statuses []resourceStatus) error {
+	if w.outputDir == "" {
+		return fmt.Errorf("--output-format=kustomize requires --output-dir")
+	}
+	basesDir := filepath.Join(w.outputDir, "bases")
+	var fileNames []string
+	for _, r := range resources {
+		fileName := fmt.Sprintf("%s-%s-%s.yaml", strings.ToLower(r.kind), r.namespace, r.name)
+		if err := writeOutputFile(basesDir, fileName, r.yaml); err != nil {
+			return err
+		}
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	var kustomization strings.Builder
+	kustomization.WriteString("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n")
+	for _, name := range fileNames {
+		kustomization.WriteString(fmt.Sprintf("  - %s\n", name))
+	}
+	if err := writeOutputFile(basesDir, "kustomization.yaml", kustomization.String()); err != nil {
+		return err
+	}
+	return writeMigrationReport(w.outputDir, statuses)
+}
+
+// helmWriter emits a minimal Helm-style bundle: a Chart.yaml, an empty
+// values.yaml, and each resource templated under templates/.
+type helmWriter struct {
+	outputDir string
+}
+
+func (w helmWriter) Write(resources []migratedResource, statuses []resourceStatus) error {
+	if w.outputDir == "" {
+		return fmt.Errorf("--output-format=helm requires --output-dir")
+	}
+	chart := "apiVersion: v2\nname: migrated-security-policies\ndescription: AuthN/AuthZ policies migrated by security-policy-migrate\nversion: 0.1.0\n"
+	if err := writeOutputFile(w.outputDir, "Chart.yaml", chart); err != nil {
+		return err
+	}
+	if err := writeOutputFile(w.outputDir, "values.yaml", "{}\n"); err != nil {
+		return err
+	}
+	templatesDir := filepath.Join(w.outputDir, "templates")
+	for _, r := range resources {
+		fileName := fmt.Sprintf("%s-%s-%s.yaml", strings.ToLower(r.kind), r.namespace, r.name)
+		if err := writeOutputFile(templatesDir, fileName, r.yaml); err != nil {
+			return err
+		}
+	}
+	return writeMigrationReport(w.outputDir, statuses)
+}
+
+// toMigratedResource parses a converted policy's rendered YAML back into a
+// migratedResource, so kubeClient.convert can hand a uniform slice to
+// OutputWriter regardless of which converter (authentication or RBAC)
+// produced the resource.
+func toMigratedResource(doc string) (migratedResource, error) {
+	obj, err := decodeUnstructured(strings.TrimPrefix(doc, "---\n"))
+	if err != nil {
+		return migratedResource{}, err
+	}
+	if obj == nil {
+		return migratedResource{}, fmt.Errorf("converted output did not contain a valid object")
+	}
+	return migratedResource{kind: obj.GetKind(), namespace: obj.GetNamespace(), name: obj.GetName(), yaml: doc}, nil
+}
+
+func yamlDocToJSONObject(doc string) (map[string]interface{}, error) {
+	obj, err := decodeUnstructured(strings.TrimPrefix(doc, "---\n"))
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return map[string]interface{}{}, nil
+	}
+	return obj.Object, nil
+}
+
+// diffAgainstCluster fetches whatever PeerAuthentication/RequestAuthentication/
+// AuthorizationPolicy already exists in the cluster for each converted
+// resource and prints a unified diff against the newly converted YAML, so
+// operators can see whether re-running the migrator would clobber a
+// hand-edited beta policy.
+func (kc *kubeClient) diffAgainstCluster(resources []migratedResource) error {
+	for _, r := range resources {
+		gvr, err := gvrForKind(r.kind)
+		if err != nil {
+			log.Printf("skipping diff for %s %s/%s: %v", r.kind, r.namespace, r.name, err)
+			continue
+		}
+		existing, err := kc.dynamicClient.Resource(gvr).Namespace(r.namespace).Get(context.TODO(), r.name, metav1.GetOptions{})
+		if kerr.IsNotFound(err) {
+			fmt.Printf("--- %s %s/%s: not present in cluster, would be created\n", r.kind, r.namespace, r.name)
+			continue
+		}
+		if err != nil {
+			log.Printf("failed to get existing %s %s/%s for diff: %v", r.kind, r.namespace, r.name, err)
+			continue
+		}
+		existingYAML, err := existingResourceYAML(existing)
+		if err != nil {
+			log.Printf("failed to render existing %s %s/%s for diff: %v", r.kind, r.namespace, r.name, err)
+			continue
+		}
+		diff := unifiedDiff(existingYAML, r.yaml)
+		if diff == "" {
+			fmt.Printf("--- %s %s/%s: unchanged\n", r.kind, r.namespace, r.name)
+			continue
+		}
+		fmt.Printf("--- %s %s/%s: cluster\n+++ %s %s/%s: converted\n%s", r.kind, r.namespace, r.name, r.kind, r.namespace, r.name, diff)
+	}
+	return nil
+}
+
+func existingResourceYAML(obj interface {
+	MarshalJSON() ([]byte, error)
+}) (string, error) {
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	yamlData, err := yaml.JSONToYAML(data)
+	if err != nil {
+		return "", err
+	}
+	return string(yamlData), nil
+}
+
+// unifiedDiff is a minimal line-based diff good enough to show operators
+// what changed between an existing cluster resource and the freshly
+// converted one; it does not attempt to find a minimal edit script.
+func unifiedDiff(before, after string) string {
+	beforeLines := strings.Split(strings.TrimRight(before, "\n"), "\n")
+	afterLines := strings.Split(strings.TrimRight(after, "\n"), "\n")
+
+	beforeSet := map[string]bool{}
+	for _, l := range beforeLines {
+		beforeSet[l] = true
+	}
+	afterSet := map[string]bool{}
+	for _, l := range afterLines {
+		afterSet[l] = true
+	}
+
+	var sb strings.Builder
+	changed := false
+	for _, l := range beforeLines {
+		if !afterSet[l] {
+			sb.WriteString("-" + l + "\n")
+			changed = true
+		}
+	}
+	for _, l := range afterLines {
+		if !beforeSet[l] {
+			sb.WriteString("+" + l + "\n")
+			changed = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return sb.String()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	authzv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// severity classifies a precheckResult the same way istioctl's precheck
+// groups its findings, so operators can tell blocking issues apart from
+// informational ones at a glance.
+type severity string
+
+const (
+	severityError severity = "Error"
+	severityWarn  severity = "Warn"
+	severityInfo  severity = "Info"
+)
+
+// precheckResult is a single finding produced while validating that a
+// cluster is ready for conversion.
+type precheckResult struct {
+	severity severity
+	message  string
+}
+
+var precheckOnly = flag.Bool("precheck", false, "run readiness checks for conversion and exit instead of converting")
+
+// precheck runs before convert to surface blocking issues: server version
+// compatibility, missing CRDs, insufficient RBAC to list the GVRs this tool
+// reads, RbacConfig/ClusterRbacConfig inclusion/exclusion modes whose
+// generated policies need review, and v1beta1 policies that already exist
+// in the cluster.
+func (kc *kubeClient) precheck() ([]precheckResult, error) {
+	var results []precheckResult
+
+	results = append(results, kc.checkServerVersion())
+	results = append(results, kc.checkCRDsInstalled()...)
+	results = append(results, kc.checkListPermissions()...)
+	results = append(results, kc.checkLeftoverRbacConfig()...)
+	results = append(results, kc.checkCoexistingPolicies()...)
+
+	return results, nil
+}
+
+func (kc *kubeClient) checkServerVersion() precheckResult {
+	version, err := kc.kubeClient.Discovery().ServerVersion()
+	if err != nil {
+		return precheckResult{severityError, fmt.Sprintf("failed to get Kubernetes server version: %v", err)}
+	}
+	return precheckResult{severityInfo, fmt.Sprintf("Kubernetes server version: %s", version.String())}
+}
+
+// allMigratedGVRs is the full set of resources this tool reads from the
+// cluster: the two v1alpha1 authentication GVRs plus the four RBAC GVRs.
+func allMigratedGVRs() []schema.GroupVersionResource {
+	return append(append([]schema.GroupVersionResource{}, gvrPolicies...), gvrRbac...)
+}
+
+func (kc *kubeClient) checkCRDsInstalled() []precheckResult {
+	var results []precheckResult
+	for _, group := range []string{"authentication.istio.io", "rbac.istio.io"} {
+		found := false
+		for _, gvr := range allMigratedGVRs() {
+			if gvr.Group == group {
+				if _, err := kc.listResources(gvr); err == nil {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			results = append(results, precheckResult{severityError, fmt.Sprintf("no CRDs found for group %q, is Istio's v1alpha1 authentication/RBAC policy installed?", group)})
+		}
+	}
+	return results
+}
+
+// checkListPermissions probes whether the current user can list every GVR
+// this tool reads, using a SelfSubjectAccessReview per resource.
+func (kc *kubeClient) checkListPermissions() []precheckResult {
+	var results []precheckResult
+	for _, gvr := range allMigratedGVRs() {
+		ssar := &authzv1.SelfSubjectAccessReview{
+			Spec: authzv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authzv1.ResourceAttributes{
+					Group:    gvr.Group,
+					Resource: gvr.Resource,
+					Verb:     "list",
+				},
+			},
+		}
+		resp, err := kc.kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), ssar, metav1.CreateOptions{})
+		if err != nil {
+			results = append(results, precheckResult{severityWarn, fmt.Sprintf("could not verify permission to list %s.%s: %v", gvr.Resource, gvr.Group, err)})
+			continue
+		}
+		if !resp.Status.Allowed {
+			results = append(results, precheckResult{severityError, fmt.Sprintf("missing permission to list %s.%s, grant %q on this resource before migrating", gvr.Resource, gvr.Group, "list")})
+		}
+	}
+	return results
+}
+
+// checkLeftoverRbacConfig flags RbacConfig/ClusterRbacConfig resources using
+// ON_WITH_INCLUSION or ON_WITH_EXCLUSION mode, whose generated
+// AuthorizationPolicies need a careful review.
+func (kc *kubeClient) checkLeftoverRbacConfig() []precheckResult {
+	var results []precheckResult
+	for _, gvr := range gvrRbac {
+		if gvr.Resource != "rbacconfigs" && gvr.Resource != "clusterrbacconfigs" {
+			continue
+		}
+		objectList, err := kc.listResources(gvr)
+		if err != nil {
+			continue
+		}
+		for _, item := range objectList.Items {
+			mode, found, _ := unstructured.NestedString(item.Object, "spec", "mode")
+			if !found {
+				continue
+			}
+			if mode == "ON_WITH_INCLUSION" || mode == "ON_WITH_EXCLUSION" {
+				results = append(results, precheckResult{severityWarn, fmt.Sprintf(
+					"%s %s/%s uses mode %s, review the generated AuthorizationPolicy inclusion/exclusion list carefully", item.GetKind(), item.GetNamespace(), item.GetName(), mode)})
+			}
+		}
+	}
+	return results
+}
+
+// checkCoexistingPolicies warns about every v1beta1 PeerAuthentication/
+// RequestAuthentication already in the cluster, since re-running convert
+// could produce a confusing, overlapping config. It does not look at the
+// v1alpha1 policies, so it does not check whether one targets the same
+// workload.
+func (kc *kubeClient) checkCoexistingPolicies() []precheckResult {
+	var results []precheckResult
+	betaGVRs := []schema.GroupVersionResource{
+		{Group: "security.istio.io", Version: "v1beta1", Resource: "peerauthentications"},
+		{Group: "security.istio.io", Version: "v1beta1", Resource: "requestauthentications"},
+	}
+	for _, gvr := range betaGVRs {
+		objectList, err := kc.listResources(gvr)
+		if err != nil {
+			continue
+		}
+		for _, item := range objectList.Items {
+			results = append(results, precheckResult{severityWarn, fmt.Sprintf(
+				"%s %s/%s already exists, re-running convert may produce a policy that overlaps with it", item.GetKind(), item.GetNamespace(), item.GetName())})
+		}
+	}
+	return results
+}
+
+// runPrecheck executes precheck, prints its findings grouped by severity,
+// and returns an error when any Error-level finding is present so callers
+// can gate migration in CI with a non-zero exit code.
+func runPrecheck(kc *kubeClient) error {
+	results, err := kc.precheck()
+	if err != nil {
+		return err
+	}
+
+	hasError := false
+	for _, sev := range []severity{severityError, severityWarn, severityInfo} {
+		for _, r := range results {
+			if r.severity != sev {
+				continue
+			}
+			log.Printf("%-5s %s", r.severity, r.message)
+			if r.severity == severityError {
+				hasError = true
+			}
+		}
+	}
+
+	if hasError {
+		return fmt.Errorf("precheck found blocking issues, fix them before running convert")
+	}
+	return nil
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// summary carries the errors accumulated while converting a single policy,
+// shared by both the authentication and RBAC converters so kubeClient.convert
+// can report failures from either the same way.
+type summary struct {
+	errors []string
+}
+
+// knownConstraintKeys are the ServiceRole rule constraints this converter
+// knows how to translate into an AuthorizationPolicy `when` condition.
+// Anything else is recorded as an error instead of silently dropped.
+var knownConstraintKeys = map[string]bool{
+	"destination.ip":   true,
+	"destination.port": true,
+	"request.headers":  true,
+	"source.ip":        true,
+	"source.principal": true,
+}
+
+// authzPolicyOutput is the AuthorizationPolicy analogue of the
+// authentication converter's beta output: a named, namespaced object ready
+// to be rendered as YAML and fed into the same output pipeline as
+// PeerAuthentication/RequestAuthentication.
+type authzPolicyOutput struct {
+	namespace string
+	name      string
+	action    string
+	selector  map[string]string
+	rules     []authzRule
+}
+
+type authzRule struct {
+	principals []string
+	ipBlocks   []string
+	methods    []string
+	paths      []string
+	when       []authzCondition
+}
+
+type authzCondition struct {
+	key    string
+	values []string
+}
+
+func (o authzPolicyOutput) toYAML() string {
+	spec := map[string]interface{}{}
+	if len(o.selector) != 0 {
+		spec["selector"] = map[string]interface{}{"matchLabels": o.selector}
+	}
+	if o.action != "" {
+		spec["action"] = o.action
+	}
+
+	var rules []interface{}
+	for _, r := range o.rules {
+		rule := map[string]interface{}{}
+		from := map[string]interface{}{}
+		if len(r.principals) != 0 {
+			from["principals"] = r.principals
+		}
+		if len(r.ipBlocks) != 0 {
+			from["ipBlocks"] = r.ipBlocks
+		}
+		if len(from) != 0 {
+			rule["from"] = []interface{}{map[string]interface{}{"source": from}}
+		}
+		to := map[string]interface{}{}
+		if len(r.methods) != 0 {
+			to["methods"] = r.methods
+		}
+		if len(r.paths) != 0 {
+			to["paths"] = r.paths
+		}
+		if len(to) != 0 {
+			rule["to"] = []interface{}{map[string]interface{}{"operation": to}}
+		}
+		if len(r.when) != 0 {
+			var when []interface{}
+			for _, c := range r.when {
+				when = append(when, map[string]interface{}{"key": c.key, "values": c.values})
+			}
+			rule["when"] = when
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) != 0 {
+		spec["rules"] = rules
+	}
+
+	obj := map[string]interface{}{
+		"apiVersion": "security.istio.io/v1beta1",
+		"kind":       "AuthorizationPolicy",
+		"metadata": map[string]interface{}{
+			"namespace": o.namespace,
+			"name":      o.name,
+		},
+		"spec": spec,
+	}
+	out, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Sprintf("# failed to marshal AuthorizationPolicy %s/%s: %v\n", o.namespace, o.name, err)
+	}
+	return "---\n" + string(out)
+}
+
+// rbacConverter turns v1alpha1 RBAC (RbacConfig/ClusterRbacConfig plus
+// ServiceRole/ServiceRoleBinding pairs) into security.istio.io/v1beta1
+// AuthorizationPolicy objects, mirroring the shape of the authentication
+// policy converter.
+type rbacConverter struct {
+	services *corev1.ServiceList
+}
+
+func newRbacConverter(services *corev1.ServiceList) *rbacConverter {
+	return &rbacConverter{services: services}
+}
+
+// Convert translates one RbacConfig/ClusterRbacConfig plus the
+// ServiceRole/ServiceRoleBinding pairs it governs into AuthorizationPolicy
+// objects. Namespaces in exclusion mode get a DENY-all baseline policy in
+// addition to any policies derived from roles, matching the documented
+// migration approach of deny-by-default once RbacConfig is retired.
+func (rc *rbacConverter) Convert(rbacConfig unstructured.Unstructured, roles, bindings []unstructured.Unstructured) ([]authzPolicyOutput, summary) {
+	var output []authzPolicyOutput
+	var sum summary
+
+	mode, _, _ := unstructured.NestedString(rbacConfig.Object, "spec", "mode")
+	exclusion, _, _ := unstructured.NestedStringSlice(rbacConfig.Object, "spec", "exclusion", "namespaces")
+	inclusion, _, _ := unstructured.NestedStringSlice(rbacConfig.Object, "spec", "inclusion", "namespaces")
+
+	namespaces := map[string]bool{}
+	switch mode {
+	case "OFF":
+		// RBAC is deliberately disabled; nothing to convert.
+		return output, sum
+	case "ON":
+		for _, role := range roles {
+			namespaces[role.GetNamespace()] = true
+		}
+	case "ON_WITH_INCLUSION":
+		for _, ns := range inclusion {
+			namespaces[ns] = true
+		}
+	case "ON_WITH_EXCLUSION":
+		excluded := map[string]bool{}
+		for _, ns := range exclusion {
+			excluded[ns] = true
+			output = append(output, authzPolicyOutput{namespace: ns, name: "namespace-deny-all", action: "DENY"})
+		}
+		for _, role := range roles {
+			if !excluded[role.GetNamespace()] {
+				namespaces[role.GetNamespace()] = true
+			}
+		}
+	default:
+		sum.errors = append(sum.errors, fmt.Sprintf("RbacConfig %s/%s has unsupported mode %q", rbacConfig.GetNamespace(), rbacConfig.GetName(), mode))
+		return output, sum
+	}
+
+	bindingsByRole := map[string][]unstructured.Unstructured{}
+	for _, binding := range bindings {
+		roleName, _, _ := unstructured.NestedString(binding.Object, "spec", "roleRef", "name")
+		key := binding.GetNamespace() + "/" + roleName
+		bindingsByRole[key] = append(bindingsByRole[key], binding)
+	}
+
+	for _, role := range roles {
+		if !namespaces[role.GetNamespace()] {
+			continue
+		}
+		key := role.GetNamespace() + "/" + role.GetName()
+		for _, binding := range bindingsByRole[key] {
+			policies, errs := rc.convertRoleBinding(role, binding)
+			sum.errors = append(sum.errors, errs...)
+			output = append(output, policies...)
+		}
+	}
+
+	return output, sum
+}
+
+// convertRoleBinding converts one ServiceRole + the ServiceRoleBinding that
+// grants it into one AuthorizationPolicy per distinct workload selector
+// named by the role's rules (a single AuthorizationPolicy only carries one
+// selector, so rules targeting different Services become separate
+// policies), deriving each selector from the Services the role's rules name.
+func (rc *rbacConverter) convertRoleBinding(role, binding unstructured.Unstructured) ([]authzPolicyOutput, []string) {
+	var errs []string
+
+	rawRules, _, _ := unstructured.NestedSlice(role.Object, "spec", "rules")
+	var rules []authzRule
+	var selectors []map[string]string
+	for _, raw := range rawRules {
+		ruleMap, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		services, _, _ := unstructured.NestedStringSlice(ruleMap, "services")
+		methods, _, _ := unstructured.NestedStringSlice(ruleMap, "methods")
+		paths, _, _ := unstructured.NestedStringSlice(ruleMap, "paths")
+
+		authRule := authzRule{methods: methods, paths: paths}
+		rawConstraints, _, _ := unstructured.NestedSlice(ruleMap, "constraints")
+		for _, rawConstraint := range rawConstraints {
+			constraint, ok := rawConstraint.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			key, _, _ := unstructured.NestedString(constraint, "key")
+			values, _, _ := unstructured.NestedStringSlice(constraint, "values")
+			if !knownConstraintKeys[key] {
+				errs = append(errs, fmt.Sprintf("ServiceRole %s/%s: unsupported constraint key %q", role.GetNamespace(), role.GetName(), key))
+				continue
+			}
+			switch key {
+			case "source.ip":
+				authRule.ipBlocks = append(authRule.ipBlocks, values...)
+			default:
+				authRule.when = append(authRule.when, authzCondition{key: key, values: values})
+			}
+		}
+		rules = append(rules, authRule)
+		selectors = append(selectors, rc.selectorForServices(role.GetNamespace(), services))
+	}
+
+	subjects, _, _ := unstructured.NestedSlice(binding.Object, "spec", "subjects")
+	var principals []string
+	// whenValues accumulates every subject's contribution to a given `when`
+	// key into one slice, so multiple subjects sharing a key (e.g. two
+	// groups) become a single OR'd condition instead of one AND'd condition
+	// per subject, which Istio would otherwise require to match all at once.
+	whenValues := map[string][]string{}
+	var whenKeys []string
+	addWhen := func(key, value string) {
+		if _, ok := whenValues[key]; !ok {
+			whenKeys = append(whenKeys, key)
+		}
+		whenValues[key] = append(whenValues[key], value)
+	}
+	for _, raw := range subjects {
+		subject, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		user, _, _ := unstructured.NestedString(subject, "user")
+		group, _, _ := unstructured.NestedString(subject, "group")
+		properties, _, _ := unstructured.NestedStringMap(subject, "properties")
+		notUser, _, _ := unstructured.NestedString(subject, "notUser")
+		notGroup, _, _ := unstructured.NestedString(subject, "notGroup")
+		notProperties, _, _ := unstructured.NestedStringMap(subject, "notProperties")
+
+		if notUser != "" || notGroup != "" || len(notProperties) != 0 {
+			errs = append(errs, fmt.Sprintf("ServiceRoleBinding %s/%s: negated subject fields (notUser/notGroup/notProperties) are not supported, the generated policy is missing this exclusion and needs manual review", binding.GetNamespace(), binding.GetName()))
+		}
+
+		switch {
+		case user == "*" && group != "":
+			errs = append(errs, fmt.Sprintf("ServiceRoleBinding %s/%s: wildcard user combined with group %q cannot be translated, review the generated policy", binding.GetNamespace(), binding.GetName(), group))
+		case user != "" && user != "*":
+			principals = append(principals, user)
+		}
+		if group != "" {
+			addWhen("request.auth.claims[groups]", group)
+		}
+		if principal, ok := properties["source.principal"]; ok {
+			principals = append(principals, principal)
+		}
+		for pk, pv := range properties {
+			if pk == "source.principal" {
+				continue
+			}
+			if !knownConstraintKeys[pk] && !strings.HasPrefix(pk, "request.headers[") {
+				errs = append(errs, fmt.Sprintf("ServiceRoleBinding %s/%s: unsupported property %q", binding.GetNamespace(), binding.GetName(), pk))
+				continue
+			}
+			addWhen(pk, pv)
+		}
+	}
+	for _, key := range whenKeys {
+		cond := authzCondition{key: key, values: whenValues[key]}
+		for i := range rules {
+			rules[i].when = append(rules[i].when, cond)
+		}
+	}
+	for i := range rules {
+		rules[i].principals = principals
+	}
+
+	if len(rules) == 0 {
+		rules = []authzRule{{principals: principals}}
+		selectors = []map[string]string{nil}
+	}
+
+	return groupRulesBySelector(role.GetNamespace(), role.GetName(), rules, selectors), errs
+}
+
+// groupRulesBySelector splits rules into one authzPolicyOutput per distinct
+// selector, naming the first policy after the role and appending a numeric
+// suffix to any further ones so their names stay unique within the namespace.
+func groupRulesBySelector(namespace, name string, rules []authzRule, selectors []map[string]string) []authzPolicyOutput {
+	type group struct {
+		selector map[string]string
+		rules    []authzRule
+	}
+	var groups []group
+	indexByKey := map[string]int{}
+	for i, rule := range rules {
+		key := selectorKey(selectors[i])
+		idx, ok := indexByKey[key]
+		if !ok {
+			idx = len(groups)
+			indexByKey[key] = idx
+			groups = append(groups, group{selector: selectors[i]})
+		}
+		groups[idx].rules = append(groups[idx].rules, rule)
+	}
+
+	outputs := make([]authzPolicyOutput, 0, len(groups))
+	for i, g := range groups {
+		outName := name
+		if i > 0 {
+			outName = fmt.Sprintf("%s-%d", name, i+1)
+		}
+		outputs = append(outputs, authzPolicyOutput{
+			namespace: namespace,
+			name:      outName,
+			selector:  g.selector,
+			rules:     g.rules,
+		})
+	}
+	return outputs
+}
+
+// selectorKey turns a selector map into a comparable string so rules can be
+// grouped by selector without relying on map identity.
+func selectorKey(selector map[string]string) string {
+	if len(selector) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(selector))
+	for k := range selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(selector[k])
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
+
+// selectorForServices derives a workload selector from the services a
+// ServiceRole rule names, by looking each one up, within the role's own
+// namespace, in the already-listed Services and reusing its spec.selector
+// labels. rc.services spans the whole cluster, so namespace must be
+// checked too or a same-named Service in an unrelated namespace could be
+// matched instead. A "*" glob or a service this tool hasn't seen in that
+// namespace yields no selector, applying the policy to the whole
+// namespace instead.
+func (rc *rbacConverter) selectorForServices(namespace string, services []string) map[string]string {
+	for _, name := range services {
+		if name == "*" {
+			continue
+		}
+		short := strings.SplitN(name, ".", 2)[0]
+		for _, svc := range rc.services.Items {
+			if svc.Namespace == namespace && svc.Name == short && len(svc.Spec.Selector) != 0 {
+				return svc.Spec.Selector
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newUnstructured(kind, namespace, name string, spec map[string]interface{}) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": kind,
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+		},
+		"spec": spec,
+	}}
+}
+
+func newService(namespace, name string, selector map[string]string) corev1.Service {
+	return corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.ServiceSpec{Selector: selector},
+	}
+}
+
+func TestSelectorForServicesScopesToNamespace(t *testing.T) {
+	services := &corev1.ServiceList{Items: []corev1.Service{
+		newService("ns-a", "frontend", map[string]string{"app": "frontend-a"}),
+		newService("ns-b", "frontend", map[string]string{"app": "frontend-b"}),
+	}}
+	rc := newRbacConverter(services)
+
+	got := rc.selectorForServices("ns-b", []string{"frontend.ns-b.svc.cluster.local"})
+	if got["app"] != "frontend-b" {
+		t.Fatalf("expected selector from ns-b's frontend Service, got %v", got)
+	}
+}
+
+func TestSelectorForServicesIgnoresOtherNamespaces(t *testing.T) {
+	services := &corev1.ServiceList{Items: []corev1.Service{
+		newService("ns-a", "frontend", map[string]string{"app": "frontend-a"}),
+	}}
+	rc := newRbacConverter(services)
+
+	got := rc.selectorForServices("ns-b", []string{"frontend"})
+	if got != nil {
+		t.Fatalf("expected no selector for a Service that only exists in a different namespace, got %v", got)
+	}
+}
+
+func TestSelectorForServicesIgnoresWildcard(t *testing.T) {
+	services := &corev1.ServiceList{Items: []corev1.Service{
+		newService("ns-a", "frontend", map[string]string{"app": "frontend-a"}),
+	}}
+	rc := newRbacConverter(services)
+
+	if got := rc.selectorForServices("ns-a", []string{"*"}); got != nil {
+		t.Fatalf("expected no selector for a \"*\" glob, got %v", got)
+	}
+}
+
+func TestConvertOnWithExclusionSkipsRolesInExcludedNamespaces(t *testing.T) {
+	rbacConfig := newUnstructured("ClusterRbacConfig", "", "default", map[string]interface{}{
+		"mode": "ON_WITH_EXCLUSION",
+		"exclusion": map[string]interface{}{
+			"namespaces": []interface{}{"excluded-ns"},
+		},
+	})
+	role := newUnstructured("ServiceRole", "excluded-ns", "reader", map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{"services": []interface{}{"*"}, "methods": []interface{}{"GET"}},
+		},
+	})
+	binding := newUnstructured("ServiceRoleBinding", "excluded-ns", "reader-binding", map[string]interface{}{
+		"subjects": []interface{}{map[string]interface{}{"user": "cluster.local/ns/excluded-ns/sa/client"}},
+		"roleRef":  map[string]interface{}{"name": "reader"},
+	})
+
+	rc := newRbacConverter(&corev1.ServiceList{})
+	output, sum := rc.Convert(rbacConfig, []unstructured.Unstructured{role}, []unstructured.Unstructured{binding})
+
+	if len(sum.errors) != 0 {
+		t.Fatalf("expected no errors, got %v", sum.errors)
+	}
+	if len(output) != 1 {
+		t.Fatalf("expected only the namespace-deny-all baseline for the excluded namespace, got %d policies: %+v", len(output), output)
+	}
+	if output[0].action != "DENY" || output[0].namespace != "excluded-ns" {
+		t.Fatalf("expected a DENY-all baseline for excluded-ns, got %+v", output[0])
+	}
+}
+
+func TestConvertOffModeIsANoOp(t *testing.T) {
+	rbacConfig := newUnstructured("ClusterRbacConfig", "", "default", map[string]interface{}{
+		"mode": "OFF",
+	})
+	role := newUnstructured("ServiceRole", "ns", "reader", map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{"services": []interface{}{"*"}, "methods": []interface{}{"GET"}},
+		},
+	})
+
+	rc := newRbacConverter(&corev1.ServiceList{})
+	output, sum := rc.Convert(rbacConfig, []unstructured.Unstructured{role}, nil)
+
+	if len(sum.errors) != 0 {
+		t.Fatalf("expected OFF mode to produce no errors, got %v", sum.errors)
+	}
+	if len(output) != 0 {
+		t.Fatalf("expected OFF mode to produce no policies, got %+v", output)
+	}
+}
+
+func TestConvertOnWithExclusionConvertsRolesInOtherNamespaces(t *testing.T) {
+	rbacConfig := newUnstructured("ClusterRbacConfig", "", "default", map[string]interface{}{
+		"mode": "ON_WITH_EXCLUSION",
+		"exclusion": map[string]interface{}{
+			"namespaces": []interface{}{"excluded-ns"},
+		},
+	})
+	role := newUnstructured("ServiceRole", "included-ns", "reader", map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{"services": []interface{}{"*"}, "methods": []interface{}{"GET"}},
+		},
+	})
+	binding := newUnstructured("ServiceRoleBinding", "included-ns", "reader-binding", map[string]interface{}{
+		"subjects": []interface{}{map[string]interface{}{"user": "cluster.local/ns/included-ns/sa/client"}},
+		"roleRef":  map[string]interface{}{"name": "reader"},
+	})
+
+	rc := newRbacConverter(&corev1.ServiceList{})
+	output, sum := rc.Convert(rbacConfig, []unstructured.Unstructured{role}, []unstructured.Unstructured{binding})
+
+	if len(sum.errors) != 0 {
+		t.Fatalf("expected no errors, got %v", sum.errors)
+	}
+	if len(output) != 2 {
+		t.Fatalf("expected the deny-all baseline plus the converted role, got %d policies: %+v", len(output), output)
+	}
+
+	var sawAllow bool
+	for _, o := range output {
+		if o.namespace == "included-ns" && o.name == "reader" {
+			sawAllow = true
+			if len(o.rules) != 1 || len(o.rules[0].methods) != 1 || o.rules[0].methods[0] != "GET" {
+				t.Fatalf("expected the converted policy to carry the GET method rule, got %+v", o.rules)
+			}
+			if len(o.rules[0].principals) != 1 || o.rules[0].principals[0] != "cluster.local/ns/included-ns/sa/client" {
+				t.Fatalf("expected the converted policy to carry the binding's principal, got %+v", o.rules[0].principals)
+			}
+		}
+	}
+	if !sawAllow {
+		t.Fatalf("expected a converted AuthorizationPolicy for the role in included-ns, got %+v", output)
+	}
+}
+
+func TestConvertRoleBindingMergesSameKeyWhenConditionsAcrossSubjects(t *testing.T) {
+	role := newUnstructured("ServiceRole", "ns", "reader", map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{"services": []interface{}{"*"}, "methods": []interface{}{"GET"}},
+		},
+	})
+	binding := newUnstructured("ServiceRoleBinding", "ns", "reader-binding", map[string]interface{}{
+		"subjects": []interface{}{
+			map[string]interface{}{"group": "g1"},
+			map[string]interface{}{"group": "g2"},
+		},
+		"roleRef": map[string]interface{}{"name": "reader"},
+	})
+
+	rc := newRbacConverter(&corev1.ServiceList{})
+	policies, errs := rc.convertRoleBinding(role, binding)
+
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if len(policies) != 1 || len(policies[0].rules) != 1 {
+		t.Fatalf("expected a single policy with a single rule, got %+v", policies)
+	}
+	when := policies[0].rules[0].when
+	if len(when) != 1 {
+		t.Fatalf("expected both groups to merge into a single when condition, got %+v", when)
+	}
+	if when[0].key != "request.auth.claims[groups]" || len(when[0].values) != 2 {
+		t.Fatalf("expected one condition OR-ing both groups' values, got %+v", when[0])
+	}
+}
+
+func TestConvertRoleBindingRecordsUnsupportedNegatedSubjectFields(t *testing.T) {
+	role := newUnstructured("ServiceRole", "ns", "reader", map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{"services": []interface{}{"*"}, "methods": []interface{}{"GET"}},
+		},
+	})
+	binding := newUnstructured("ServiceRoleBinding", "ns", "reader-binding", map[string]interface{}{
+		"subjects": []interface{}{
+			map[string]interface{}{"user": "*", "notGroup": "blocked-group"},
+		},
+		"roleRef": map[string]interface{}{"name": "reader"},
+	})
+
+	rc := newRbacConverter(&corev1.ServiceList{})
+	_, errs := rc.convertRoleBinding(role, binding)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected exactly one error for the unsupported notGroup field, got %v", errs)
+	}
+}
+
+func TestConvertRoleBindingSplitsRulesWithDifferentSelectorsIntoSeparatePolicies(t *testing.T) {
+	services := &corev1.ServiceList{Items: []corev1.Service{
+		newService("ns", "frontend", map[string]string{"app": "frontend"}),
+		newService("ns", "backend", map[string]string{"app": "backend"}),
+	}}
+	role := newUnstructured("ServiceRole", "ns", "reader", map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{"services": []interface{}{"frontend"}, "methods": []interface{}{"GET"}},
+			map[string]interface{}{"services": []interface{}{"backend"}, "methods": []interface{}{"POST"}},
+		},
+	})
+	binding := newUnstructured("ServiceRoleBinding", "ns", "reader-binding", map[string]interface{}{
+		"subjects": []interface{}{map[string]interface{}{"user": "cluster.local/ns/ns/sa/client"}},
+		"roleRef":  map[string]interface{}{"name": "reader"},
+	})
+
+	rc := newRbacConverter(services)
+	policies, errs := rc.convertRoleBinding(role, binding)
+
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if len(policies) != 2 {
+		t.Fatalf("expected the two differently-selected rules to split into two policies, got %+v", policies)
+	}
+	for _, p := range policies {
+		if len(p.rules) != 1 {
+			t.Fatalf("expected each split policy to carry exactly the rule for its own selector, got %+v", p)
+		}
+	}
+	if policies[0].name != "reader" || policies[1].name != "reader-2" {
+		t.Fatalf("expected the first policy to keep the role's name and the second to get a numeric suffix, got %q and %q", policies[0].name, policies[1].name)
+	}
+}
+
+func TestConvertRoleBindingRecordsUnsupportedConstraint(t *testing.T) {
+	role := newUnstructured("ServiceRole", "ns", "reader", map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{
+				"services": []interface{}{"*"},
+				"constraints": []interface{}{
+					map[string]interface{}{"key": "some.custom.key", "values": []interface{}{"x"}},
+				},
+			},
+		},
+	})
+	binding := newUnstructured("ServiceRoleBinding", "ns", "reader-binding", map[string]interface{}{
+		"subjects": []interface{}{map[string]interface{}{"user": "*"}},
+		"roleRef":  map[string]interface{}{"name": "reader"},
+	})
+
+	rc := newRbacConverter(&corev1.ServiceList{})
+	_, errs := rc.convertRoleBinding(role, binding)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected exactly one error for the unsupported constraint key, got %v", errs)
+	}
+}